@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcTOTPKey is the RFC 6238 test vector secret, "12345678901234567890" in
+// base32 (the standard SHA-1 case used by its Appendix B test table).
+const rfcTOTPKey = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPAt(t *testing.T) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(rfcTOTPKey)
+	if err != nil {
+		t.Fatalf("decode key: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		step int64
+		want string
+	}{
+		{name: "RFC 6238 test vector at T=59", step: 59 / 30, want: "287082"},
+		{name: "code is zero-padded to 6 digits", step: 30, want: "026920"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := totpAt(key, tc.step); got != tc.want {
+				t.Errorf("totpAt(step=%d) = %q, want %q", tc.step, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	at := time.Unix(30*30, 0)
+
+	cases := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{name: "correct zero-padded code", code: "026920", want: true},
+		{name: "leading zeros dropped is rejected", code: "26920", want: false},
+		{name: "wrong code is rejected", code: "000000", want: false},
+		{name: "empty code is rejected", code: "", want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := checkTOTP(rfcTOTPKey, tc.code, at); got != tc.want {
+				t.Errorf("checkTOTP(%q) = %v, want %v", tc.code, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckTOTPToleratesClockSkew(t *testing.T) {
+	key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(rfcTOTPKey)
+	step := int64(100)
+	code := totpAt(key, step)
+
+	oneStepOff := time.Unix((step+1)*30, 0)
+	if !checkTOTP(rfcTOTPKey, code, oneStepOff) {
+		t.Error("checkTOTP should accept a code from one step earlier")
+	}
+
+	twoStepsOff := time.Unix((step+2)*30, 0)
+	if checkTOTP(rfcTOTPKey, code, twoStepsOff) {
+		t.Error("checkTOTP should reject a code two steps outside the window")
+	}
+}
+
+func TestVerifySessionCookie(t *testing.T) {
+	sessionSecret = []byte("test-secret")
+
+	signed := signSessionID("abc123")
+	sigForOtherID := signSessionID("xyz789")
+
+	cases := []struct {
+		name   string
+		value  string
+		wantID string
+		wantOK bool
+	}{
+		{name: "valid signature", value: signed, wantID: "abc123", wantOK: true},
+		{name: "id swapped but signature kept from another session", value: "abc123." + sigForOtherID[len("xyz789."):], wantOK: false},
+		{name: "missing separator", value: "no-dot-here", wantOK: false},
+		{name: "empty value", value: "", wantOK: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, ok := verifySessionCookie(tc.value)
+			if ok != tc.wantOK {
+				t.Fatalf("verifySessionCookie(%q) ok = %v, want %v", tc.value, ok, tc.wantOK)
+			}
+			if ok && id != tc.wantID {
+				t.Errorf("verifySessionCookie(%q) id = %q, want %q", tc.value, id, tc.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+)
+
+// checkFlag verifies a submitted flag against a Challenge according to its
+// FlagType. userID is only consulted for dynamic, per-team flags.
+func checkFlag(c *Challenge, userID, submitted string) (bool, error) {
+	switch c.FlagType {
+	case FlagStatic, "":
+		return submitted == c.Flag, nil
+	case FlagRegex:
+		re, err := regexp.Compile(c.Flag)
+		if err != nil {
+			return false, fmt.Errorf("challenge %s has an invalid flag regexp: %w", c.ID, err)
+		}
+		return re.MatchString(submitted), nil
+	case FlagDynamic:
+		return submitted == dynamicFlag(c, userID), nil
+	default:
+		return false, fmt.Errorf("challenge %s has unknown flag type %q", c.ID, c.FlagType)
+	}
+}
+
+// dynamicFlag derives the per-team flag as flag{hmac-sha256(saltPrefix, userID:challengeID)}.
+func dynamicFlag(c *Challenge, userID string) string {
+	mac := hmac.New(sha256.New, []byte(c.SaltPrefix))
+	mac.Write([]byte(userID + ":" + c.ID))
+	return fmt.Sprintf("flag{%s}", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// pointsForSolve applies exponential decay based on how many teams have
+// already solved the challenge: points = min + (max-min) * exp(-solves*k).
+func pointsForSolve(c *Challenge, priorSolves int) int {
+	decayed := float64(c.MinPoints) + float64(c.MaxPoints-c.MinPoints)*math.Exp(-float64(priorSolves)*c.DecayK)
+	return int(math.Round(decayed))
+}
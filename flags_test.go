@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+func TestCheckFlag(t *testing.T) {
+	dynamic := &Challenge{ID: "c1", FlagType: FlagDynamic, SaltPrefix: "s3cr3t"}
+
+	cases := []struct {
+		name      string
+		challenge *Challenge
+		userID    string
+		submitted string
+		want      bool
+		wantErr   bool
+	}{
+		{
+			name:      "static match",
+			challenge: &Challenge{ID: "c1", FlagType: FlagStatic, Flag: "flag{static}"},
+			submitted: "flag{static}",
+			want:      true,
+		},
+		{
+			name:      "static mismatch",
+			challenge: &Challenge{ID: "c1", FlagType: FlagStatic, Flag: "flag{static}"},
+			submitted: "flag{wrong}",
+			want:      false,
+		},
+		{
+			name:      "empty flag type treated as static",
+			challenge: &Challenge{ID: "c1", FlagType: "", Flag: "flag{static}"},
+			submitted: "flag{static}",
+			want:      true,
+		},
+		{
+			name:      "regex match",
+			challenge: &Challenge{ID: "c1", FlagType: FlagRegex, Flag: `^flag\{[a-f0-9]{8}\}$`},
+			submitted: "flag{deadbeef}",
+			want:      true,
+		},
+		{
+			name:      "regex mismatch",
+			challenge: &Challenge{ID: "c1", FlagType: FlagRegex, Flag: `^flag\{[a-f0-9]{8}\}$`},
+			submitted: "flag{not-hex!}",
+			want:      false,
+		},
+		{
+			name:      "invalid regex is an error",
+			challenge: &Challenge{ID: "c1", FlagType: FlagRegex, Flag: `(unterminated`},
+			submitted: "anything",
+			wantErr:   true,
+		},
+		{
+			name:      "dynamic match for the derived team",
+			challenge: dynamic,
+			userID:    "team-a",
+			submitted: dynamicFlag(dynamic, "team-a"),
+			want:      true,
+		},
+		{
+			name:      "dynamic flag doesn't verify for a different team",
+			challenge: dynamic,
+			userID:    "team-a",
+			submitted: dynamicFlag(dynamic, "team-b"),
+			want:      false,
+		},
+		{
+			name:      "unknown flag type is an error",
+			challenge: &Challenge{ID: "c1", FlagType: "quantum"},
+			submitted: "anything",
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := checkFlag(tc.challenge, tc.userID, tc.submitted)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("checkFlag() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && got != tc.want {
+				t.Errorf("checkFlag() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPointsForSolve(t *testing.T) {
+	c := &Challenge{MinPoints: 100, MaxPoints: 500, DecayK: 0.1}
+
+	cases := []struct {
+		name        string
+		priorSolves int
+		want        int
+	}{
+		{name: "first solve gets the max", priorSolves: 0, want: 500},
+		{name: "decays toward the min as solves grow", priorSolves: 10, want: 247},
+		{name: "converges to the min for many solves", priorSolves: 1000, want: 100},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := pointsForSolve(c, tc.priorSolves); got != tc.want {
+				t.Errorf("pointsForSolve(%d) = %d, want %d", tc.priorSolves, got, tc.want)
+			}
+		})
+	}
+}
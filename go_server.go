@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -35,20 +37,16 @@ func main() {
 	if os.Getenv("DATABASE_URL") == "" {
 		log.Println("warning: DATABASE_URL is not set")
 	}
-	if os.Getenv("SESSION_SECRET") == "" {
+	if secret := os.Getenv("SESSION_SECRET"); secret == "" {
 		log.Println("warning: SESSION_SECRET is not set")
+		sessionSecret = []byte(randomToken(32))
+	} else {
+		sessionSecret = []byte(secret)
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/challenges", handleChallenges)
-	mux.HandleFunc("/api/challenges/", handleChallengeByID)
-	mux.HandleFunc("/api/users/", handleUserByID)
-	mux.HandleFunc("/api/leaderboard", handleGetLeaderboard)
+	mux.Handle(apiPrefix, newHandlerFromEnv())
 	mux.HandleFunc("/uploads/", handleDownloadFile)
-	mux.HandleFunc("/api/register", handleRegister)
-	mux.HandleFunc("/api/login", handleLogin)
-	mux.HandleFunc("/api/logout", handleLogout)
-	mux.HandleFunc("/api/me", handleGetMe)
 	mux.HandleFunc("/", serveStaticOrNotFound)
 
 	srv := &http.Server{Handler: loggingMiddleware(mux)}
@@ -125,6 +123,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(rec, r)
 		dur := time.Since(start).Milliseconds()
 		if strings.HasPrefix(path, "/api") {
+			NewCounter(fmt.Sprintf(`http_requests_total{path=%q,status="%d"}`, path, rec.statusCode)).Add(1)
 			line := fmt.Sprintf("%s %s %d in %dms", r.Method, path, rec.statusCode, dur)
 			if rec.body.Len() > 0 && rec.statusCode < 400 {
 				b := rec.body.String()
@@ -145,25 +144,51 @@ type responseRecorder struct {
 }
 
 func (r *responseRecorder) WriteHeader(s int) { r.statusCode = s; r.ResponseWriter.WriteHeader(s) }
+
+// Write records the body for the request-log line, except on a streaming
+// response (SSE): those stay open for minutes and append every heartbeat,
+// which would otherwise grow body without bound for the life of the
+// connection.
 func (r *responseRecorder) Write(b []byte) (int, error) {
-	r.body.Write(b)
+	if r.Header().Get("Content-Type") != "text/event-stream" {
+		r.body.Write(b)
+	}
 	return r.ResponseWriter.Write(b)
 }
 
-func handleChallenges(w http.ResponseWriter, r *http.Request) {
+// Hijack and Flush forward to the embedded ResponseWriter so routes that
+// need a raw connection (websocket upgrade) or chunked flushing (SSE)
+// still work wrapped in a responseRecorder.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
+	}
+	return hj.Hijack()
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func handleChallenges(_ *Handler, w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode([]interface{}{})
 	case http.MethodPost:
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{"message": "created"})
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"message": "created"})
+		})(w, r)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
-func handleChallengeByID(w http.ResponseWriter, r *http.Request) {
+func handleChallengeByID(_ *Handler, w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/api/challenges/")
 	if id == "" {
 		http.NotFound(w, r)
@@ -172,45 +197,193 @@ func handleChallengeByID(w http.ResponseWriter, r *http.Request) {
 	if strings.HasSuffix(id, "/submit") {
 		id = strings.TrimSuffix(id, "/submit")
 		if r.Method == http.MethodPost {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]any{"correct": false})
+			requireAuth(func(w http.ResponseWriter, r *http.Request) {
+				handleSubmitFlag(w, r, id)
+			})(w, r)
+			return
+		}
+	}
+	if strings.HasSuffix(id, "/solves") {
+		id = strings.TrimSuffix(id, "/solves")
+		if r.Method == http.MethodGet {
+			handleGetSolves(w, r, id)
+			return
+		}
+	}
+	if strings.HasSuffix(id, "/files") {
+		id = strings.TrimSuffix(id, "/files")
+		if r.Method == http.MethodPost {
+			requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+				handleUploadChallengeFile(nil, w, r, id)
+			})(w, r)
 			return
 		}
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"id": id})
 }
-func handleUserByID(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/users/")
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"id": id})
+
+type submitFlagRequest struct {
+	Flag string `json:"flag"`
+}
+
+type submitFlagResponse struct {
+	Correct    bool `json:"correct"`
+	Points     int  `json:"points,omitempty"`
+	FirstBlood bool `json:"firstBlood,omitempty"`
 }
-func handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+
+func handleSubmitFlag(w http.ResponseWriter, r *http.Request, challengeID string) {
+	userID := currentUserID(r)
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	challenge, ok := db.getChallenge(challengeID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !db.allowAttempt(userID, challengeID) {
+		http.Error(w, "too many attempts, slow down", http.StatusTooManyRequests)
+		return
+	}
+
+	var req submitFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	correct, err := checkFlag(challenge, userID, req.Flag)
+	if err != nil {
+		log.Printf("checkFlag: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// A correct submission of an already-solved challenge doesn't score
+	// again (recordSubmission dedupes by user+challenge below), so don't
+	// award phantom points or broadcast a phantom solve for it.
+	alreadySolved := db.alreadySolved(challengeID, userID)
+
+	oldRank := rankOf(db.sortedScoreboard(), userID)
+
+	sub := &Submission{
+		UserID:      userID,
+		ChallengeID: challengeID,
+		Flag:        req.Flag,
+		Correct:     correct,
+		SubmittedAt: time.Now(),
+	}
+	if correct {
+		if !alreadySolved {
+			sub.Points = pointsForSolve(challenge, db.solveCount(challengeID))
+		}
+		NewCounter(`submissions_total{result="correct"}`).Add(1)
+	} else {
+		NewCounter(`submissions_total{result="incorrect"}`).Add(1)
+	}
+	db.recordSubmission(sub)
+
+	if correct && !alreadySolved {
+		newRank := rankOf(db.sortedScoreboard(), userID)
+		leaderboardHub.Broadcast(Event{
+			Type:      "solve",
+			User:      userID,
+			Challenge: challengeID,
+			Points:    sub.Points,
+			RankDelta: oldRank - newRank,
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode([]interface{}{})
+	json.NewEncoder(w).Encode(submitFlagResponse{
+		Correct:    sub.Correct,
+		Points:     sub.Points,
+		FirstBlood: sub.FirstBlood,
+	})
 }
-func handleDownloadFile(w http.ResponseWriter, r *http.Request) {
-	name := strings.TrimPrefix(r.URL.Path, "/uploads/")
-	if strings.Contains(name, "..") || strings.Contains(name, "/") {
-		http.Error(w, "forbidden", http.StatusForbidden)
+
+type solveEntry struct {
+	UserID      string    `json:"userId"`
+	Points      int       `json:"points"`
+	FirstBlood  bool      `json:"firstBlood"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+func handleGetSolves(w http.ResponseWriter, r *http.Request, challengeID string) {
+	if _, ok := db.getChallenge(challengeID); !ok {
+		http.NotFound(w, r)
 		return
 	}
-	http.ServeFile(w, r, filepath.Join(uploadDir, name))
+	solves := db.solvesFor(challengeID)
+	sort.Slice(solves, func(i, j int) bool { return solves[i].SubmittedAt.Before(solves[j].SubmittedAt) })
+
+	entries := make([]solveEntry, 0, len(solves))
+	for _, s := range solves {
+		entries = append(entries, solveEntry{
+			UserID:      s.UserID,
+			Points:      s.Points,
+			FirstBlood:  s.FirstBlood,
+			SubmittedAt: s.SubmittedAt,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
 }
-func handleRegister(w http.ResponseWriter, r *http.Request) {
+func handleUserByID(_ *Handler, w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/users/")
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "registered"})
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
 }
-func handleLogin(w http.ResponseWriter, r *http.Request) {
+
+type leaderboardEntry struct {
+	Rank         int       `json:"rank"`
+	UserID       string    `json:"userId"`
+	Points       int       `json:"points"`
+	LastSolvedAt time.Time `json:"lastSolvedAt"`
+}
+
+func handleGetLeaderboard(_ *Handler, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "logged-in"})
+	json.NewEncoder(w).Encode(leaderboardEntries(db.sortedScoreboard()))
 }
-func handleLogout(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
-func handleGetMe(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+func leaderboardEntries(rows []scoreboardRow) []leaderboardEntry {
+	entries := make([]leaderboardEntry, 0, len(rows))
+	for i, row := range rows {
+		entries = append(entries, leaderboardEntry{
+			Rank:         i + 1,
+			UserID:       row.UserID,
+			Points:       row.Points,
+			LastSolvedAt: row.LastSolvedAt,
+		})
+	}
+	return entries
 }
 
+// handleDownloadFile serves /uploads/{challengeID}/{logicalName} by looking
+// up the challenge's recorded content hash and streaming the matching blob,
+// rather than trusting the request path as a filesystem path directly.
+func handleDownloadFile(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/uploads/")
+	challengeID, filename, ok := strings.Cut(rest, "/")
+	if !ok || challengeID == "" || filename == "" {
+		http.NotFound(w, r)
+		return
+	}
+	hash, ok := db.getChallengeFileHash(challengeID, filename)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", hash)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	http.ServeFile(w, r, blobPath(hash))
+}
 func serveStaticOrNotFound(w http.ResponseWriter, r *http.Request) {
 	p := r.URL.Path
 	if strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/uploads/") {
@@ -233,5 +406,3 @@ func serveStaticOrNotFound(w http.ResponseWriter, r *http.Request) {
 	}
 	http.NotFound(w, r)
 }
-
-func isAuthenticated(r *http.Request) bool { return false }
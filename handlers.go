@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// apiHandler is the signature for an entry in the route table. It gets the
+// dispatching Handler so it can see the permission flags ServeHTTP already
+// checked, without each handler re-reading the environment itself.
+type apiHandler func(h *Handler, w http.ResponseWriter, r *http.Request)
+
+// route is one entry in the handler table: the handler plus whether it
+// needs read and/or write permission to be reachable. A route that serves
+// both GET and POST (like "challenges") sets both and ServeHTTP checks the
+// one that matches the request method.
+type route struct {
+	handler     apiHandler
+	permitRead  bool
+	permitWrite bool
+}
+
+const apiPrefix = "/api/"
+
+// handlers is the prefix table dispatched by Handler.ServeHTTP, keyed by
+// path relative to apiPrefix. A key ending in "/" is a prefix match
+// (longest one wins); a bare key is an exact match.
+var handlers = map[string]route{
+	"challenges":  {handler: handleChallenges, permitRead: true, permitWrite: true},
+	"challenges/": {handler: handleChallengeByID, permitRead: true, permitWrite: true},
+	"users/":      {handler: handleUserByID, permitRead: true},
+	"leaderboard": {handler: handleGetLeaderboard, permitRead: true},
+	"register":    {handler: handleRegister, permitWrite: true},
+	"login":       {handler: handleLogin, permitWrite: true},
+	"logout":      {handler: handleLogout, permitWrite: true},
+	"me":          {handler: handleGetMe, permitRead: true},
+	"uploads/":    {handler: handleUploadBlob, permitRead: true, permitWrite: true},
+	"metrics":     {handler: handleMetrics, permitRead: true},
+
+	"leaderboard/stream": {handler: handleLeaderboardStream, permitRead: true},
+	"leaderboard/events": {handler: handleLeaderboardEvents, permitRead: true},
+}
+
+// Handler is the API dispatcher. PermitRead/PermitWrite gate whole classes
+// of routes, so the same binary can run as a read-only "public CTF"
+// frontend or a read-write "admin console" just by flipping flags.
+// RequiredPassword additionally HTTP Basic-auth gates the whole API, for
+// running a local admin console without wiring it into the user/session
+// system.
+type Handler struct {
+	PermitRead       bool
+	PermitWrite      bool
+	RequiredPassword string
+}
+
+// newHandlerFromEnv builds the Handler main() mounts, configured by
+// environment variables so ops can flip modes without a recompile.
+func newHandlerFromEnv() *Handler {
+	return &Handler{
+		PermitRead:       true,
+		PermitWrite:      os.Getenv("API_READ_ONLY") == "",
+		RequiredPassword: os.Getenv("ADMIN_PASSWORD"),
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt, ok := lookupRoute(strings.TrimPrefix(r.URL.Path, apiPrefix))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.RequiredPassword != "" {
+		if _, pass, ok := r.BasicAuth(); !ok || pass != h.RequiredPassword {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if isMutatingMethod(r.Method) {
+		if !rt.permitWrite || !h.PermitWrite {
+			http.Error(w, "forbidden: write access disabled", http.StatusForbidden)
+			return
+		}
+	} else if !rt.permitRead || !h.PermitRead {
+		http.Error(w, "forbidden: read access disabled", http.StatusForbidden)
+		return
+	}
+
+	rt.handler(h, w, r)
+}
+
+// lookupRoute does longest-prefix match on path against handlers: an exact
+// entry wins outright, otherwise the longest "/"-suffixed key that path
+// starts with wins.
+func lookupRoute(path string) (route, bool) {
+	if rt, ok := handlers[path]; ok {
+		return rt, true
+	}
+	var best string
+	var bestRoute route
+	for prefix, rt := range handlers {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(path, prefix) && len(prefix) > len(best) {
+			best, bestRoute = prefix, rt
+		}
+	}
+	return bestRoute, best != ""
+}
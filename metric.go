@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type metricKind int
+
+const (
+	counterKind metricKind = iota
+	gaugeKind
+)
+
+// Metric is a single named counter or gauge value, safe for concurrent use.
+// Label sets (e.g. `http_requests_total{path="/api/login",status="200"}`)
+// are just baked into the name, so each distinct label combination is its
+// own entry in the registry.
+type Metric struct {
+	name  string
+	kind  metricKind
+	value int64
+}
+
+func (m *Metric) Add(delta int64) { atomic.AddInt64(&m.value, delta) }
+func (m *Metric) Set(v int64)     { atomic.StoreInt64(&m.value, v) }
+func (m *Metric) get() int64      { return atomic.LoadInt64(&m.value) }
+
+var (
+	metricsMu sync.RWMutex
+	metrics   = make(map[string]*Metric)
+	startTime = time.Now()
+)
+
+// NewCounter returns the named counter, registering it on first use so
+// call sites can declare metrics right where they're incremented.
+func NewCounter(name string) *Metric { return getOrCreateMetric(name, counterKind) }
+
+// NewGauge returns the named gauge, registering it on first use.
+func NewGauge(name string) *Metric { return getOrCreateMetric(name, gaugeKind) }
+
+func getOrCreateMetric(name string, kind metricKind) *Metric {
+	metricsMu.RLock()
+	m, ok := metrics[name]
+	metricsMu.RUnlock()
+	if ok {
+		return m
+	}
+
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if m, ok := metrics[name]; ok {
+		return m
+	}
+	m = &Metric{name: name, kind: kind}
+	metrics[name] = m
+	return m
+}
+
+// collectProcessMetrics refreshes the process-level gauges; it's called
+// lazily on scrape rather than on a ticker so an idle server does no
+// background work for metrics nobody is reading.
+func collectProcessMetrics() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	NewGauge("goroutines").Set(int64(runtime.NumGoroutine()))
+	NewGauge("heap_bytes").Set(int64(ms.HeapAlloc))
+	NewGauge("uptime_seconds").Set(int64(time.Since(startTime).Seconds()))
+}
+
+// handleMetrics serves GET /api/metrics in a simple line-oriented format
+// by default, or a valid Prometheus exposition when ?format=prometheus.
+func handleMetrics(_ *Handler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	collectProcessMetrics()
+
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if r.URL.Query().Get("format") == "prometheus" {
+		writePrometheusMetrics(w, names)
+		return
+	}
+	writePlainMetrics(w, names)
+}
+
+func writePlainMetrics(w http.ResponseWriter, names []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, name := range names {
+		m := metrics[name]
+		kind := "counter"
+		if m.kind == gaugeKind {
+			kind = "gauge"
+		}
+		fmt.Fprintf(w, "%s %s %d\n", kind, name, m.get())
+	}
+}
+
+func writePrometheusMetrics(w http.ResponseWriter, names []string) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	announced := make(map[string]bool)
+	for _, name := range names {
+		m := metrics[name]
+		base, _, _ := strings.Cut(name, "{")
+		if !announced[base] {
+			announced[base] = true
+			typ := "counter"
+			if m.kind == gaugeKind {
+				typ = "gauge"
+			}
+			fmt.Fprintf(w, "# HELP %s %s\n", base, base)
+			fmt.Fprintf(w, "# TYPE %s %s\n", base, typ)
+		}
+		fmt.Fprintf(w, "%s %d\n", name, m.get())
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one incremental leaderboard update, broadcast to every
+// subscriber when a submission is accepted.
+type Event struct {
+	Type      string `json:"type"`
+	User      string `json:"user,omitempty"`
+	Challenge string `json:"challenge,omitempty"`
+	Points    int    `json:"points,omitempty"`
+	RankDelta int    `json:"rankDelta,omitempty"`
+}
+
+const subscriberBufferSize = 16
+
+// Hub fans Event out to every registered subscriber channel. A slow
+// subscriber whose buffer is full is dropped rather than letting it stall
+// the broadcaster.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewHub() *Hub { return &Hub{subscribers: make(map[chan Event]struct{})} }
+
+func (h *Hub) Register(ch chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[ch] = struct{}{}
+}
+
+func (h *Hub) Unregister(ch chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (h *Hub) Broadcast(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+var leaderboardHub = NewHub()
+
+const leaderboardSnapshotSize = 50
+const heartbeatInterval = 20 * time.Second
+
+type leaderboardSnapshotMsg struct {
+	Type    string             `json:"type"`
+	Entries []leaderboardEntry `json:"entries"`
+}
+
+func leaderboardSnapshot() leaderboardSnapshotMsg {
+	rows := db.sortedScoreboard()
+	if len(rows) > leaderboardSnapshotSize {
+		rows = rows[:leaderboardSnapshotSize]
+	}
+	return leaderboardSnapshotMsg{Type: "snapshot", Entries: leaderboardEntries(rows)}
+}
+
+func rankOf(rows []scoreboardRow, userID string) int {
+	for i, row := range rows {
+		if row.UserID == userID {
+			return i + 1
+		}
+	}
+	return len(rows) + 1
+}
+
+// originAllowed ties the websocket/SSE endpoints to the configured public
+// host, same as a browser's same-origin policy would. With no PUBLIC_HOST
+// configured (local dev) every origin is allowed.
+func originAllowed(r *http.Request) bool {
+	publicHost := os.Getenv("PUBLIC_HOST")
+	if publicHost == "" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == publicHost
+}
+
+// handleLeaderboardStream implements GET /api/leaderboard/stream: a
+// websocket that sends the current top-N snapshot on connect, then a
+// diff-shaped Event per accepted submission.
+func handleLeaderboardStream(_ *Handler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !originAllowed(r) {
+		http.Error(w, "forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	conn, rw, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	ch := make(chan Event, subscriberBufferSize)
+	leaderboardHub.Register(ch)
+	defer leaderboardHub.Unregister(ch)
+
+	if payload, err := json.Marshal(leaderboardSnapshot()); err == nil {
+		if writeWSFrame(conn, wsOpText, payload) != nil {
+			return
+		}
+	}
+
+	closed := make(chan struct{})
+	go watchForWSClose(rw.Reader, closed)
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-heartbeat.C:
+			if writeWSFrame(conn, wsOpPing, nil) != nil {
+				return
+			}
+		case evt, ok := <-ch:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(evt)
+			if err != nil {
+				continue
+			}
+			if writeWSFrame(conn, wsOpText, payload) != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleLeaderboardEvents implements the SSE fallback at
+// GET /api/leaderboard/events for clients that can't (or won't) upgrade.
+func handleLeaderboardEvents(_ *Handler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !originAllowed(r) {
+		http.Error(w, "forbidden origin", http.StatusForbidden)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := make(chan Event, subscriberBufferSize)
+	leaderboardHub.Register(ch)
+	defer leaderboardHub.Unregister(ch)
+
+	writeSSE(w, "snapshot", leaderboardSnapshot())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case evt, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeSSE(w, "solve", evt)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
+// --- minimal RFC 6455 server-side websocket support (no external deps) ---
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+)
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, nil, errors.New("expected websocket upgrade")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := rw.WriteString(resp); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+func writeWSFrame(conn net.Conn, opcode byte, payload []byte) error {
+	var header []byte
+	finOp := 0x80 | opcode
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header = []byte{finOp, byte(length)}
+	case length <= 0xFFFF:
+		header = []byte{finOp, 126, byte(length >> 8), byte(length)}
+	default:
+		header = make([]byte, 10)
+		header[0] = finOp
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	if _, err := conn.Write(header); err != nil {
+		return err
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+	_, err := conn.Write(payload)
+	return err
+}
+
+// watchForWSClose reads (and discards) client frames so the connection
+// stays readable, closing `closed` on a close frame or any read error.
+func watchForWSClose(r *bufio.Reader, closed chan<- struct{}) {
+	for {
+		opcode, _, err := readWSFrame(r)
+		if err != nil || opcode == wsOpClose {
+			close(closed)
+			return
+		}
+	}
+}
+
+// maxWSFramePayload caps the payload length we'll allocate for a single
+// incoming frame. Clients on this endpoint only ever send tiny control
+// frames (pings/close), so this is generous headroom, not a real limit.
+const maxWSFramePayload = 1 << 20 // 1MiB
+
+func readWSFrame(r *bufio.Reader) (opcode byte, payload []byte, err error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, nil, err
+	}
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext))
+		if length < 0 {
+			return 0, nil, errors.New("invalid websocket frame length")
+		}
+	}
+	if length > maxWSFramePayload {
+		return 0, nil, fmt.Errorf("websocket frame payload too large: %d bytes", length)
+	}
+
+	var maskKey [4]byte
+	if masked {
+		m := make([]byte, 4)
+		if _, err := io.ReadFull(r, m); err != nil {
+			return 0, nil, err
+		}
+		copy(maskKey[:], m)
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
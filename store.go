@@ -0,0 +1,246 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// FlagType selects how a Challenge's flag is verified against a submission.
+type FlagType string
+
+const (
+	FlagStatic  FlagType = "static"  // exact string match
+	FlagRegex   FlagType = "regex"   // Flag is a regexp the submission must match
+	FlagDynamic FlagType = "dynamic" // per-team salted hash, derived at check time
+)
+
+// Challenge is the static definition of a scoreable task.
+type Challenge struct {
+	ID         string
+	Title      string
+	Category   string
+	MinPoints  int
+	MaxPoints  int
+	DecayK     float64 // decay rate in points = min + (max-min)*exp(-solves*k)
+	FlagType   FlagType
+	Flag       string            // static value, or regexp source, depending on FlagType
+	SaltPrefix string            // secret prefix mixed into the per-team dynamic flag
+	Files      map[string]string // logical filename -> content-hash (populated by the upload subsystem)
+}
+
+// Submission records one flag attempt against a Challenge.
+type Submission struct {
+	ID          int64
+	UserID      string
+	ChallengeID string
+	Flag        string
+	Correct     bool
+	Points      int
+	FirstBlood  bool
+	SubmittedAt time.Time
+}
+
+// store is the in-memory backing store for challenges, submissions and
+// solve state. It stands in for the DATABASE_URL-backed store referenced
+// at startup; swapping it for a real SQL-backed implementation later
+// should not require touching the handlers.
+type store struct {
+	mu          sync.RWMutex
+	nextSubID   int64
+	challenges  map[string]*Challenge
+	submissions []*Submission
+	solved      map[string]map[string]*Submission // challengeID -> userID -> first correct submission
+	attempts    map[string][]time.Time            // "userID:challengeID" -> recent attempt timestamps
+
+	users        map[string]*User    // userID -> User
+	usersByName  map[string]string   // username -> userID
+	usersByEmail map[string]string   // email -> userID
+	sessions     map[string]*Session // sessionID -> Session
+}
+
+func newStore() *store {
+	return &store{
+		challenges:   make(map[string]*Challenge),
+		solved:       make(map[string]map[string]*Submission),
+		attempts:     make(map[string][]time.Time),
+		users:        make(map[string]*User),
+		usersByName:  make(map[string]string),
+		usersByEmail: make(map[string]string),
+		sessions:     make(map[string]*Session),
+	}
+}
+
+var db = newStore()
+
+func (s *store) getChallenge(id string) (*Challenge, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.challenges[id]
+	return c, ok
+}
+
+// putChallenge stores a challenge definition, replacing any with the same ID.
+func (s *store) putChallenge(c *Challenge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[c.ID] = c
+}
+
+// setChallengeFile records that a challenge's logical filename now maps to
+// the given content hash, overwriting any previous mapping.
+func (s *store) setChallengeFile(challengeID, logicalName, hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.challenges[challengeID]
+	if !ok {
+		return false
+	}
+	if c.Files == nil {
+		c.Files = make(map[string]string)
+	}
+	c.Files[logicalName] = hash
+	return true
+}
+
+func (s *store) getChallengeFileHash(challengeID, logicalName string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.challenges[challengeID]
+	if !ok {
+		return "", false
+	}
+	hash, ok := c.Files[logicalName]
+	return hash, ok
+}
+
+const (
+	rateLimitWindow = 30 * time.Second
+	rateLimitMax    = 5
+)
+
+// allowAttempt applies a sliding-window rate limit per (user, challenge)
+// pair, pruning stale timestamps as it goes.
+func (s *store) allowAttempt(userID, challengeID string) bool {
+	key := userID + ":" + challengeID
+	now := time.Now()
+	cutoff := now.Add(-rateLimitWindow)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.attempts[key][:0]
+	for _, t := range s.attempts[key] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) >= rateLimitMax {
+		s.attempts[key] = kept
+		return false
+	}
+	s.attempts[key] = append(kept, now)
+	return true
+}
+
+// recordSubmission stores the submission and, if correct, updates solve
+// and first-blood state atomically with the write.
+func (s *store) recordSubmission(sub *Submission) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextSubID++
+	sub.ID = s.nextSubID
+	s.submissions = append(s.submissions, sub)
+
+	if !sub.Correct {
+		return
+	}
+	byUser, ok := s.solved[sub.ChallengeID]
+	if !ok {
+		byUser = make(map[string]*Submission)
+		s.solved[sub.ChallengeID] = byUser
+	}
+	if _, already := byUser[sub.UserID]; !already {
+		sub.FirstBlood = len(byUser) == 0
+		byUser[sub.UserID] = sub
+	}
+}
+
+// solveCount returns the number of distinct users who have solved the
+// challenge so far, used to compute point decay.
+func (s *store) solveCount(challengeID string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.solved[challengeID])
+}
+
+// alreadySolved reports whether the user already has a recorded solve for
+// the challenge, so callers can short-circuit a repeat correct submission
+// before it's scored as if it were new.
+func (s *store) alreadySolved(challengeID, userID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.solved[challengeID][userID]
+	return ok
+}
+
+// solvesFor returns each user's first correct submission for a challenge,
+// in no particular order.
+func (s *store) solvesFor(challengeID string) []*Submission {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	byUser := s.solved[challengeID]
+	out := make([]*Submission, 0, len(byUser))
+	for _, sub := range byUser {
+		out = append(out, sub)
+	}
+	return out
+}
+
+// scoreboardRow is one aggregated leaderboard entry.
+type scoreboardRow struct {
+	UserID       string
+	Points       int
+	LastSolvedAt time.Time
+}
+
+// scoreboard aggregates SUM(points) per user across correct submissions.
+func (s *store) scoreboard() []scoreboardRow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totals := make(map[string]*scoreboardRow)
+	for _, byUser := range s.solved {
+		for userID, sub := range byUser {
+			row, ok := totals[userID]
+			if !ok {
+				row = &scoreboardRow{UserID: userID}
+				totals[userID] = row
+			}
+			row.Points += sub.Points
+			if sub.SubmittedAt.After(row.LastSolvedAt) {
+				row.LastSolvedAt = sub.SubmittedAt
+			}
+		}
+	}
+	rows := make([]scoreboardRow, 0, len(totals))
+	for _, row := range totals {
+		rows = append(rows, *row)
+	}
+	return rows
+}
+
+// sortedScoreboard returns scoreboard() ranked highest points first,
+// tiebroken by earlier last-solve timestamp. Shared by the leaderboard
+// endpoint and the realtime snapshot/rank-delta logic so they can't drift.
+func (s *store) sortedScoreboard() []scoreboardRow {
+	rows := s.scoreboard()
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Points != rows[j].Points {
+			return rows[i].Points > rows[j].Points
+		}
+		return rows[i].LastSolvedAt.Before(rows[j].LastSolvedAt)
+	})
+	return rows
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxUploadBytes = 500 << 20 // 500MiB
+	partialSuffix         = ".partial"
+)
+
+func maxUploadBytes() int64 {
+	if v := os.Getenv("MAX_UPLOAD_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultMaxUploadBytes
+}
+
+func blobPath(hash string) string    { return filepath.Join(uploadDir, hash) }
+func partialPath(hash string) string { return blobPath(hash) + partialSuffix }
+
+// handleUploadChallengeFile implements POST /api/challenges/{id}/files:
+// a regular multipart/form-data upload that hashes each part while
+// streaming it to a content-addressed blob, then records the logical
+// filename -> hash mapping on the challenge.
+func handleUploadChallengeFile(_ *Handler, w http.ResponseWriter, r *http.Request, challengeID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := db.getChallenge(challengeID); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxUploadBytes()); err != nil {
+		http.Error(w, "invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	stored := make(map[string]string)
+	for logicalName, headers := range r.MultipartForm.File {
+		for _, fh := range headers {
+			hash, err := storeChallengeFile(fh)
+			if err != nil {
+				if err == errUploadTooLarge {
+					http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			db.setChallengeFile(challengeID, logicalName, hash)
+			stored[logicalName] = hash
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"files": stored})
+}
+
+var errUploadTooLarge = errors.New("upload exceeds max size")
+
+func storeChallengeFile(fh *multipart.FileHeader) (string, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(uploadDir, "upload-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hasher := sha256.New()
+	limited := io.LimitReader(src, maxUploadBytes()+1)
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), limited)
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if written > maxUploadBytes() {
+		tmp.Close()
+		return "", errUploadTooLarge
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if err := os.Rename(tmpPath, blobPath(hash)); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// handleUploadBlob implements the lower-level, content-addressed endpoint:
+// PUT /api/uploads/{sha256} streams (and optionally resumes) a blob upload,
+// HEAD /api/uploads/{sha256} probes how many bytes are already stored.
+func handleUploadBlob(_ *Handler, w http.ResponseWriter, r *http.Request) {
+	requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.TrimPrefix(r.URL.Path, "/api/uploads/")
+		if len(hash) != sha256.Size*2 || strings.ContainsAny(hash, "/.") {
+			http.Error(w, "invalid content hash", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodHead:
+			probeResumeOffset(w, r, hash)
+		case http.MethodPut:
+			putBlob(w, r, hash)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})(w, r)
+}
+
+func probeResumeOffset(w http.ResponseWriter, r *http.Request, hash string) {
+	if stat, err := os.Stat(blobPath(hash)); err == nil {
+		w.Header().Set("ETag", hash)
+		w.Header().Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	stat, err := os.Stat(partialPath(hash))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", stat.Size()-1))
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+// parseContentRange parses "bytes N-*/total", returning resumable=false
+// when the header is absent (a plain, single-shot PUT of the whole blob).
+// total is -1 when the client didn't declare it (open-ended "*").
+func parseContentRange(header string) (offset, total int64, resumable bool, err error) {
+	if header == "" {
+		return 0, -1, false, nil
+	}
+	rest, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, 0, false, errors.New("invalid Content-Range")
+	}
+	rangePart, totalPart, ok := strings.Cut(rest, "/")
+	if !ok {
+		return 0, 0, false, errors.New("invalid Content-Range")
+	}
+	startPart, _, ok := strings.Cut(rangePart, "-")
+	if !ok {
+		return 0, 0, false, errors.New("invalid Content-Range")
+	}
+	start, err := strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, false, errors.New("invalid Content-Range start")
+	}
+	if totalPart == "*" {
+		return start, -1, true, nil
+	}
+	total, err = strconv.ParseInt(totalPart, 10, 64)
+	if err != nil {
+		return 0, 0, false, errors.New("invalid Content-Range total")
+	}
+	return start, total, true, nil
+}
+
+func putBlob(w http.ResponseWriter, r *http.Request, hash string) {
+	offset, total, resumable, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if total > maxUploadBytes() {
+		http.Error(w, "upload exceeds max size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	partial := partialPath(hash)
+	var f *os.File
+	if resumable && offset > 0 {
+		f, err = os.OpenFile(partial, os.O_WRONLY, 0644)
+		if err != nil {
+			http.Error(w, "no upload in progress at that offset", http.StatusConflict)
+			return
+		}
+		if stat, err := f.Stat(); err != nil || stat.Size() != offset {
+			f.Close()
+			http.Error(w, "resume offset does not match stored bytes", http.StatusConflict)
+			return
+		}
+	} else {
+		f, err = os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			http.Error(w, "failed to open upload target", http.StatusInternalServerError)
+			return
+		}
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		http.Error(w, "seek failed", http.StatusInternalServerError)
+		return
+	}
+
+	hasher := sha256.New()
+	limited := io.LimitReader(r.Body, maxUploadBytes()-offset+1)
+	written, err := io.Copy(io.MultiWriter(f, hasher), limited)
+	if err != nil {
+		http.Error(w, "failed writing upload", http.StatusInternalServerError)
+		return
+	}
+	newSize := offset + written
+	if newSize > maxUploadBytes() {
+		f.Close()
+		os.Remove(partial)
+		http.Error(w, "upload exceeds max size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if total >= 0 && newSize < total {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", newSize-1))
+		w.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+
+	// Single-shot upload (no prior chunks): the hasher already covers the
+	// whole blob, so we can verify without a re-read.
+	gotHash := hex.EncodeToString(hasher.Sum(nil))
+	if offset == 0 {
+		if gotHash != hash {
+			f.Close()
+			os.Remove(partial)
+			http.Error(w, "content hash mismatch", http.StatusBadRequest)
+			return
+		}
+		f.Close()
+		if err := os.Rename(partial, blobPath(hash)); err != nil {
+			http.Error(w, "failed to finalize upload", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		f.Close()
+		if err := finalizeBlob(partial, hash); err != nil {
+			os.Remove(partial)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", hash)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// finalizeBlob re-hashes an assembled multi-chunk partial upload (the
+// per-request hasher in putBlob only ever sees the last chunk) and renames
+// it into place only if the full content matches the claimed hash.
+func finalizeBlob(partial, wantHash string) error {
+	f, err := os.Open(partial)
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != wantHash {
+		return fmt.Errorf("content hash mismatch: got %s, want %s", got, wantHash)
+	}
+	return os.Rename(partial, blobPath(wantHash))
+}